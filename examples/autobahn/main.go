@@ -0,0 +1,76 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+// Command autobahn runs a WebSocket echo server against which the Autobahn
+// WebSocket testsuite fuzzing client can be pointed to verify RFC 6455
+// conformance. Run it with `make autobahn` from the repository root, which
+// starts this server, runs the dockerized fuzzing client against it using
+// fuzzingclient.json, and checks the resulting report with check.sh.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/zhangpeihao/go-websocket/websocket"
+)
+
+var addr = flag.String("addr", ":9001", "address to serve the echo endpoint on")
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  65536,
+	WriteBufferSize: 65536,
+	CheckOrigin:     func(r *http.Request) bool { return true },
+}
+
+func echo(w http.ResponseWriter, r *http.Request) {
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Println("upgrade:", err)
+		return
+	}
+	defer conn.Close()
+
+	for {
+		opCode, reader, err := conn.NextReader()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Println("read:", err)
+			}
+			return
+		}
+		writer, err := conn.NextWriter(opCode)
+		if err != nil {
+			log.Println("next writer:", err)
+			return
+		}
+		if _, err := io.Copy(writer, reader); err != nil {
+			log.Println("copy:", err)
+			return
+		}
+		if err := writer.Close(); err != nil {
+			log.Println("close frame:", err)
+			return
+		}
+	}
+}
+
+func main() {
+	flag.Parse()
+	http.HandleFunc("/", echo)
+	log.Printf("autobahn echo server listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
@@ -34,6 +34,15 @@ type BinaryConn struct {
 	ws *Conn
 }
 
+// SetCompressionLevel sets the flate compression level used for frames
+// written after this call. It must be called before the first call to
+// Write. Valid levels are those accepted by compress/flate, from
+// flate.BestSpeed to flate.BestCompression; if it is never called the
+// package-level default set by SetDefaultCompressionLevel is used.
+func (conn *BinaryConn) SetCompressionLevel(level int) error {
+	return conn.ws.SetCompressionLevel(level)
+}
+
 // Connect a web socket hosr, and upgrade to web socket.
 //
 // Examples:
@@ -60,10 +69,23 @@ func Connect(urlstr string, readBufSize, writeBufSize int) (conn *BinaryConn, re
 }
 
 // Create a server side connection.
+//
+// NewBianryConn is a thin wrapper around Upgrader kept for backward
+// compatibility with the old package-level Upgrade function, which did no
+// origin checking at all; CheckOrigin is set to always allow so that
+// existing callers of Connect (which sends the full request URL, not just
+// the scheme and host, as Origin) keep working. New code that needs real
+// origin checking, subprotocol negotiation, or custom handshake error
+// handling should use Upgrader directly.
 func NewBianryConn(w http.ResponseWriter, r *http.Request, responseHeader http.Header,
 	readBufSize, writeBufSize int) (conn *BinaryConn, err error) {
 	var ws *Conn
-	if ws, err = Upgrade(w, r.Header, responseHeader, 1024, 1024); err != nil {
+	u := &Upgrader{
+		ReadBufferSize:  readBufSize,
+		WriteBufferSize: writeBufSize,
+		CheckOrigin:     func(*http.Request) bool { return true },
+	}
+	if ws, err = u.Upgrade(w, r, responseHeader); err != nil {
 		return
 	}
 	conn = &BinaryConn{
@@ -75,6 +97,9 @@ func NewBianryConn(w http.ResponseWriter, r *http.Request, responseHeader http.H
 // Read reads data from the connection.
 // Read can be made to time out and return a Error with Timeout() == true
 // after a fixed time limit; see SetDeadline and SetReadDeadline.
+// If the peer closes the connection with a close frame, Read returns a
+// *CloseError describing the peer's status code and reason instead of a
+// bare io.EOF.
 func (conn *BinaryConn) Read(b []byte) (n int, err error) {
 	var opCode int
 	var r io.Reader
@@ -103,7 +128,6 @@ FOR_LOOP:
 			return
 		}
 	}
-	return
 }
 
 // Write writes data to the connection.
@@ -0,0 +1,118 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Close frame status codes defined in RFC 6455 Section 7.4.1.
+const (
+	CloseNormalClosure           = 1000
+	CloseGoingAway               = 1001
+	CloseProtocolError           = 1002
+	CloseUnsupportedData         = 1003
+	CloseNoStatusReceived        = 1005
+	CloseAbnormalClosure         = 1006
+	CloseInvalidFramePayloadData = 1007
+	ClosePolicyViolation         = 1008
+	CloseMessageTooBig           = 1009
+	CloseMandatoryExtension      = 1010
+	CloseInternalServerErr       = 1011
+	CloseServiceRestart          = 1012
+	CloseTryAgainLater           = 1013
+	CloseTLSHandshake            = 1015
+)
+
+// CloseError is returned by NextReader/Read/BinaryConn.Read when the peer
+// sends a close frame. Code is one of the Close constants (or a
+// peer-defined value in the 3000-4999 range), and Text is the optional,
+// UTF-8 close reason the peer supplied.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	if e.Text == "" {
+		return fmt.Sprintf("websocket: close %d", e.Code)
+	}
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+// IsCloseError returns true if err is a *CloseError whose code is one of the
+// given codes. With no codes it matches any *CloseError.
+func IsCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	if len(codes) == 0 {
+		return true
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnexpectedCloseError returns true if err is a *CloseError whose code is
+// not one of the expected codes. It is meant to be used so that callers can
+// log an error for any close code other than the ones they treat as a
+// normal end of the session, e.g.:
+//
+//	if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseNoStatusReceived) {
+//		log.Printf("unexpected close: %v", err)
+//	}
+func IsUnexpectedCloseError(err error, codes ...int) bool {
+	var ce *CloseError
+	if !errors.As(err, &ce) {
+		return false
+	}
+	for _, code := range codes {
+		if ce.Code == code {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatCloseMessage builds the payload of a close frame from a status code
+// and an optional UTF-8 reason. RFC 6455 Section 7.4 reserves
+// CloseNoStatusReceived (1005) to mean "no status code was present" and
+// forbids sending it on the wire, so that code produces an empty close
+// payload instead of the usual 2-byte status code plus reason.
+func FormatCloseMessage(code int, text string) []byte {
+	if code == CloseNoStatusReceived {
+		return []byte{}
+	}
+	buf := make([]byte, 2+len(text))
+	binary.BigEndian.PutUint16(buf, uint16(code))
+	copy(buf[2:], text)
+	return buf
+}
+
+// WriteCloseMessage sends a close frame with the given status code and
+// reason, using deadline as the write deadline for the frame. It does
+// not wait for the peer's close frame in return; callers that need a clean
+// handshake should keep reading until NextReader returns a *CloseError.
+func (c *Conn) WriteCloseMessage(code int, text string, deadline time.Time) error {
+	return c.WriteControl(OpClose, FormatCloseMessage(code, text), deadline)
+}
@@ -0,0 +1,62 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import "testing"
+
+func TestFormatCloseMessage(t *testing.T) {
+	msg := FormatCloseMessage(CloseNormalClosure, "bye")
+	if len(msg) != 5 || string(msg[2:]) != "bye" {
+		t.Fatalf("FormatCloseMessage() = %v", msg)
+	}
+
+	if msg := FormatCloseMessage(CloseNoStatusReceived, "ignored"); len(msg) != 0 {
+		t.Fatalf("FormatCloseMessage(CloseNoStatusReceived, ...) = %v, want empty", msg)
+	}
+}
+
+func TestIsCloseError(t *testing.T) {
+	err := &CloseError{Code: CloseGoingAway, Text: "shutting down"}
+
+	if !IsCloseError(err) {
+		t.Error("IsCloseError(err) = false, want true")
+	}
+	if !IsCloseError(err, CloseGoingAway, CloseNormalClosure) {
+		t.Error("IsCloseError(err, CloseGoingAway, ...) = false, want true")
+	}
+	if IsCloseError(err, CloseNormalClosure) {
+		t.Error("IsCloseError(err, CloseNormalClosure) = true, want false")
+	}
+	if IsCloseError(errNotAClose) {
+		t.Error("IsCloseError(non-close error) = true, want false")
+	}
+}
+
+func TestIsUnexpectedCloseError(t *testing.T) {
+	err := &CloseError{Code: CloseProtocolError}
+
+	if !IsUnexpectedCloseError(err, CloseNormalClosure, CloseGoingAway) {
+		t.Error("expected CloseProtocolError to be unexpected")
+	}
+	if IsUnexpectedCloseError(err, CloseProtocolError) {
+		t.Error("expected CloseProtocolError to be expected when listed")
+	}
+}
+
+var errNotAClose = errTestSentinel("not a close error")
+
+type errTestSentinel string
+
+func (e errTestSentinel) Error() string { return string(e) }
@@ -0,0 +1,265 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+)
+
+// permessageDeflate is the extension token for RFC 7692 compression
+// negotiated during the handshake in Upgrade and NewClient.
+const permessageDeflate = "permessage-deflate"
+
+// DefaultCompressionLevel is the flate compression level used when a
+// connection enables compression without calling SetCompressionLevel.
+const DefaultCompressionLevel = flate.BestSpeed
+
+// defaultCompressionLevel is the package-level fallback honored by
+// BinaryConn.SetCompressionLevel and any Conn that does not set its own
+// level explicitly.
+var defaultCompressionLevel = DefaultCompressionLevel
+
+// SetDefaultCompressionLevel changes the package-level default flate
+// compression level used by connections that do not set their own.
+func SetDefaultCompressionLevel(level int) {
+	defaultCompressionLevel = level
+}
+
+// minCompressionLevel and maxCompressionLevel bound the flate levels that
+// flateWriterPools indexes by, so a pooled writer can be reused for every
+// valid level instead of only the package default.
+const (
+	minCompressionLevel = flate.HuffmanOnly
+	maxCompressionLevel = flate.BestCompression
+)
+
+// maxCompressionWindow is the largest dictionary compress/flate will use, so
+// a connection's sliding compression window is capped to it rather than
+// growing without bound for the life of the connection.
+const maxCompressionWindow = 32768
+
+// flateWriterPools holds one pool per compression level, shared by
+// connections that negotiated no_context_takeover: since every message on
+// such a connection starts from a clean window anyway, a pooled, Reset
+// writer is indistinguishable from a fresh one. flateReaderPool is the read
+// side of that same case and needs no per-level split: inflating does not
+// depend on the level the peer compressed with.
+//
+// Connections with context takeover enabled (the default) do not use these
+// pools; see compressWriter and decompressReader.
+var (
+	flateWriterPools [maxCompressionLevel - minCompressionLevel + 1]sync.Pool
+	flateReaderPool  = sync.Pool{
+		New: func() interface{} {
+			return flate.NewReader(nil)
+		},
+	}
+)
+
+func init() {
+	for i := range flateWriterPools {
+		level := i + minCompressionLevel
+		flateWriterPools[i].New = func() interface{} {
+			w, _ := flate.NewWriter(nil, level)
+			return w
+		}
+	}
+}
+
+func flateWriterPoolForLevel(level int) *sync.Pool {
+	if level < minCompressionLevel {
+		level = minCompressionLevel
+	}
+	if level > maxCompressionLevel {
+		level = maxCompressionLevel
+	}
+	return &flateWriterPools[level-minCompressionLevel]
+}
+
+// deflateFinalBlock is appended to a peer's compressed payload before
+// inflating it. RFC 7692 Section 7.2.2 has the sender strip the 4-octet
+// sync-flush marker (0x00 0x00 0xff 0xff) that terminates every compressed
+// message; the receiver must restore it, plus an empty final block, or
+// compress/flate's reader returns io.ErrUnexpectedEOF instead of a clean
+// EOF.
+var deflateFinalBlock = []byte{0x00, 0x00, 0xff, 0xff, 0x01, 0x00, 0x00, 0xff, 0xff}
+
+// deflateSyncFlushTrailer is the 4-octet marker compressWriter strips from
+// the end of every message per RFC 7692 Section 7.2.1.
+var deflateSyncFlushTrailer = deflateFinalBlock[:4]
+
+// appendDict extends dict with payload and trims it back to
+// maxCompressionWindow bytes, keeping only the most recent history a future
+// message could reference.
+func appendDict(dict, payload []byte) []byte {
+	dict = append(dict, payload...)
+	if len(dict) > maxCompressionWindow {
+		dict = dict[len(dict)-maxCompressionWindow:]
+	}
+	return dict
+}
+
+// compressWriter returns a WriteCloser that deflates data written to it and,
+// on Close, writes the RFC 7692 framed compressed bytes to w.
+//
+// When noContextTakeover is false (context takeover enabled, the default),
+// *dict is the connection's sliding compression window: the raw bytes of
+// every previous message written on this connection, up to
+// maxCompressionWindow. The returned writer compresses against that window
+// via flate.NewWriterDict and Close extends *dict with this message's
+// payload so later messages keep benefiting from it. compress/flate's
+// Writer.Reset cannot change a writer's dictionary, so this case cannot use
+// the level pools below; a fresh *flate.Writer is the only way to seed one.
+//
+// When noContextTakeover is true, dict is ignored: each message starts from
+// a clean window, so the flate.Writer comes from (and on Close returns to)
+// the pool for level instead.
+func compressWriter(w io.Writer, level int, noContextTakeover bool, dict *[]byte) io.WriteCloser {
+	c := &flateWriteCloser{dest: w, noContextTakeover: noContextTakeover, dict: dict}
+	if noContextTakeover {
+		pool := flateWriterPoolForLevel(level)
+		fw := pool.Get().(*flate.Writer)
+		fw.Reset(&c.buf)
+		c.fw = fw
+		c.pool = pool
+		return c
+	}
+	fw, _ := flate.NewWriterDict(&c.buf, level, *dict)
+	c.fw = fw
+	return c
+}
+
+// decompressReader returns a ReadCloser that inflates RFC 7692 framed data
+// read from r. See compressWriter for the meaning of noContextTakeover and
+// dict; here dict is extended with the inflated bytes on Close instead of
+// the raw ones.
+func decompressReader(r io.Reader, noContextTakeover bool, dict *[]byte) io.ReadCloser {
+	framed := io.MultiReader(r, bytes.NewReader(deflateFinalBlock))
+	if noContextTakeover {
+		rc := flateReaderPool.Get().(io.ReadCloser)
+		rc.(flate.Resetter).Reset(framed, nil)
+		return &flateReadCloser{rc: rc, pooled: true}
+	}
+	return &flateReadCloser{rc: flate.NewReaderDict(framed, *dict), dict: dict}
+}
+
+// flateWriteCloser buffers the deflated form of everything written to it so
+// that Close can strip the trailing RFC 7692 sync-flush marker before
+// handing the result to dest.
+type flateWriteCloser struct {
+	dest              io.Writer
+	buf               bytes.Buffer
+	raw               bytes.Buffer
+	fw                *flate.Writer
+	pool              *sync.Pool
+	noContextTakeover bool
+	dict              *[]byte
+}
+
+func (c *flateWriteCloser) Write(p []byte) (int, error) {
+	if !c.noContextTakeover {
+		c.raw.Write(p)
+	}
+	return c.fw.Write(p)
+}
+
+func (c *flateWriteCloser) Close() error {
+	if err := c.fw.Flush(); err != nil {
+		return err
+	}
+	if c.pool != nil {
+		c.pool.Put(c.fw)
+	}
+	if !c.noContextTakeover {
+		*c.dict = appendDict(*c.dict, c.raw.Bytes())
+	}
+
+	out := c.buf.Bytes()
+	if bytes.HasSuffix(out, deflateSyncFlushTrailer) {
+		out = out[:len(out)-len(deflateSyncFlushTrailer)]
+	}
+	_, err := c.dest.Write(out)
+	return err
+}
+
+// flateReadCloser is the decompression counterpart of flateWriteCloser.
+type flateReadCloser struct {
+	rc     io.ReadCloser
+	pooled bool
+	dict   *[]byte
+	raw    bytes.Buffer
+}
+
+func (c *flateReadCloser) Read(p []byte) (int, error) {
+	n, err := c.rc.Read(p)
+	if n > 0 && c.dict != nil {
+		c.raw.Write(p[:n])
+	}
+	return n, err
+}
+
+func (c *flateReadCloser) Close() error {
+	if c.pooled {
+		flateReaderPool.Put(c.rc)
+	} else {
+		c.rc.Close()
+	}
+	if c.dict != nil {
+		*c.dict = appendDict(*c.dict, c.raw.Bytes())
+	}
+	return nil
+}
+
+// decompress is a convenience wrapper around decompressReader for callers,
+// such as NextReader, that already have the whole compressed message in
+// memory and just want the inflated bytes back.
+func decompress(payload []byte, noContextTakeover bool, dict *[]byte) ([]byte, error) {
+	r := decompressReader(bytes.NewReader(payload), noContextTakeover, dict)
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+// negotiateCompressionExtensions inspects the Sec-WebSocket-Extensions
+// values offered by a peer and, if permessage-deflate is present, returns
+// the response extension parameters to echo back. ok is false when the
+// peer did not offer the extension.
+func negotiateCompressionExtensions(offered []string, noContextTakeover bool) (response string, ok bool) {
+	for _, field := range offered {
+		for _, token := range strings.Split(field, ",") {
+			params := strings.Split(token, ";")
+			if strings.TrimSpace(params[0]) != permessageDeflate {
+				continue
+			}
+			response = permessageDeflate
+			if noContextTakeover {
+				response += "; server_no_context_takeover; client_no_context_takeover"
+			}
+			return response, true
+		}
+	}
+	return "", false
+}
+
+// peerRequestedNoContextTakeover reports whether a negotiated
+// Sec-WebSocket-Extensions value carries either no_context_takeover
+// parameter.
+func peerRequestedNoContextTakeover(extension string) bool {
+	return strings.Contains(extension, "no_context_takeover")
+}
@@ -0,0 +1,124 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"compress/flate"
+	"io/ioutil"
+	"math/rand"
+	"testing"
+)
+
+func roundTrip(t *testing.T, payload []byte, noContextTakeover bool, writeDict, readDict *[]byte) {
+	var buf bytes.Buffer
+	w := compressWriter(&buf, defaultCompressionLevel, noContextTakeover, writeDict)
+	if _, err := w.Write(payload); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r := decompressReader(&buf, noContextTakeover, readDict)
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	r.Close()
+
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("round trip mismatch: got %d bytes, want %d bytes", len(got), len(payload))
+	}
+}
+
+func TestCompressionRoundTrip(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 50; i++ {
+		payload := make([]byte, rnd.Intn(4096))
+		rnd.Read(payload)
+		var writeDict, readDict []byte
+		roundTrip(t, payload, i%2 == 0, &writeDict, &readDict)
+	}
+}
+
+func TestCompressionRoundTripPooledWriterReuse(t *testing.T) {
+	var writeDict, readDict []byte
+	for i := 0; i < 10; i++ {
+		roundTrip(t, []byte("the quick brown fox jumps over the lazy dog"), true, &writeDict, &readDict)
+	}
+}
+
+// TestCompressionContextTakeover checks that, with context takeover enabled
+// (noContextTakeover false), the dictionary built up from earlier messages
+// on a connection carries forward: a later message can be decompressed
+// correctly even though decompressReader only ever sees that one message's
+// bytes, and repeating an earlier message compresses to fewer bytes once
+// its content is already in the dictionary.
+func TestCompressionContextTakeover(t *testing.T) {
+	var writeDict, readDict []byte
+	long := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 100)
+	messages := [][]byte{
+		[]byte("an unrelated first message"),
+		long,
+		long,
+	}
+
+	// flate.BestSpeed (the package default) doesn't search far enough back
+	// to exploit a preset dictionary; use BestCompression so the effect of
+	// carrying the dictionary forward is observable.
+	var compressedSizes []int
+	for _, payload := range messages {
+		var buf bytes.Buffer
+		w := compressWriter(&buf, flate.BestCompression, false, &writeDict)
+		if _, err := w.Write(payload); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("Close: %v", err)
+		}
+		compressedSizes = append(compressedSizes, buf.Len())
+
+		r := decompressReader(&buf, false, &readDict)
+		got, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("ReadAll: %v", err)
+		}
+		r.Close()
+		if !bytes.Equal(got, payload) {
+			t.Fatalf("round trip mismatch for %q: got %q", payload, got)
+		}
+	}
+
+	if compressedSizes[2] >= compressedSizes[1] {
+		t.Fatalf("expected repeating message 1 to compress smaller once it was in the dictionary, got sizes %v", compressedSizes)
+	}
+}
+
+func TestNegotiateCompressionExtensions(t *testing.T) {
+	response, ok := negotiateCompressionExtensions([]string{"permessage-deflate; client_max_window_bits"}, false)
+	if !ok || response != permessageDeflate {
+		t.Fatalf("negotiateCompressionExtensions() = %q, %v", response, ok)
+	}
+
+	response, ok = negotiateCompressionExtensions([]string{"permessage-deflate"}, true)
+	if !ok || !peerRequestedNoContextTakeover(response) {
+		t.Fatalf("expected no_context_takeover in response, got %q", response)
+	}
+
+	if _, ok = negotiateCompressionExtensions([]string{"x-webkit-deflate-frame"}, false); ok {
+		t.Fatal("expected no match for unsupported extension")
+	}
+}
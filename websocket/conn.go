@@ -0,0 +1,576 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Frame opcodes as defined in RFC 6455 Section 11.8.
+const (
+	OpContinuation = 0
+	OpText         = 1
+	OpBinary       = 2
+	OpClose        = 8
+	OpPing         = 9
+	OpPong         = 10
+)
+
+// keyGUID is appended to a Sec-WebSocket-Key before hashing to produce the
+// Sec-WebSocket-Accept value, per RFC 6455 Section 1.3.
+var keyGUID = []byte("258EAFA5-E914-47DA-95CA-C5AB0DC85B11")
+
+// HandshakeError describes a failed attempt to upgrade an HTTP connection
+// to a WebSocket connection.
+type HandshakeError struct {
+	Message string
+}
+
+func (e HandshakeError) Error() string { return e.Message }
+
+// Conn represents a WebSocket connection negotiated by Upgrade or
+// NewClient.
+type Conn struct {
+	conn     net.Conn
+	isServer bool
+	br       *bufio.Reader
+
+	subprotocol string
+
+	enableCompression     bool
+	compressionLevel      int
+	noContextTakeover     bool   // affects frames this side writes
+	peerNoContextTakeover bool   // affects frames this side reads
+	writeDict             []byte // sliding window for noContextTakeover-disabled writes
+	readDict              []byte // sliding window for peerNoContextTakeover-disabled reads
+
+	pingHandler    func(appData string) error
+	pongHandler    func(appData string) error
+	writeControlMu sync.Mutex
+	writeMu        sync.Mutex
+
+	readErr error
+}
+
+// newConn wraps netConn as a Conn. isServer selects the masking rules used
+// on the wire: clients mask frames they send, servers never do.
+func newConn(netConn net.Conn, isServer bool) *Conn {
+	c := &Conn{
+		conn:             netConn,
+		isServer:         isServer,
+		br:               bufio.NewReader(netConn),
+		compressionLevel: defaultCompressionLevel,
+	}
+	c.pingHandler = func(appData string) error {
+		return c.WriteControl(OpPong, []byte(appData), time.Now().Add(writeWait))
+	}
+	c.pongHandler = func(string) error { return nil }
+	return c
+}
+
+// Subprotocol returns the subprotocol negotiated during the handshake, or
+// the empty string if none was negotiated.
+func (c *Conn) Subprotocol() string {
+	return c.subprotocol
+}
+
+// SetCompressionLevel sets the flate compression level used for frames
+// written after this call. It must be called before the first call to
+// NextWriter/Write. Valid levels are those accepted by compress/flate, from
+// flate.BestSpeed to flate.BestCompression.
+func (c *Conn) SetCompressionLevel(level int) error {
+	c.compressionLevel = level
+	return nil
+}
+
+// Close closes the underlying network connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+// SetReadDeadline sets the deadline for future reads, including the
+// internal reads NextReader performs while waiting for a frame.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the deadline for future writes, including the
+// internal writes NextWriter.Close performs to flush a frame.
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+// frameHeader is the result of parsing one RFC 6455 frame header, with the
+// (already unmasked) payload that followed it.
+type frameHeader struct {
+	fin     bool
+	rsv1    bool
+	opCode  int
+	payload []byte
+}
+
+// isControlOpCode reports whether opCode is one of the control frame
+// opcodes (close, ping, pong), which RFC 6455 Section 5.5 never allows to be
+// fragmented or to carry more than 125 bytes of payload.
+func isControlOpCode(opCode int) bool {
+	return opCode == OpClose || opCode == OpPing || opCode == OpPong
+}
+
+// isReservedOpCode reports whether opCode is one of the opcodes RFC 6455
+// Section 11.8 reserves for future use. A compliant peer must fail the
+// connection if it receives one.
+func isReservedOpCode(opCode int) bool {
+	return (opCode >= 3 && opCode <= 7) || (opCode >= 11 && opCode <= 15)
+}
+
+func (c *Conn) readFrame() (frameHeader, error) {
+	var h frameHeader
+	var lead [2]byte
+	if _, err := io.ReadFull(c.br, lead[:]); err != nil {
+		return h, err
+	}
+	h.fin = lead[0]&0x80 != 0
+	h.rsv1 = lead[0]&0x40 != 0
+	h.opCode = int(lead[0] & 0xf)
+	masked := lead[1]&0x80 != 0
+	length := int64(lead[1] & 0x7f)
+
+	if isReservedOpCode(h.opCode) {
+		return h, &CloseError{Code: CloseProtocolError, Text: "websocket: use of reserved opcode"}
+	}
+	if isControlOpCode(h.opCode) {
+		if !h.fin {
+			return h, &CloseError{Code: CloseProtocolError, Text: "websocket: fragmented control frame"}
+		}
+		if length > 125 {
+			return h, &CloseError{Code: CloseProtocolError, Text: "websocket: control frame payload too large"}
+		}
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return h, err
+		}
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(c.br, ext[:]); err != nil {
+			return h, err
+		}
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+
+	// RFC 6455 Section 5.1: clients must mask every frame they send and
+	// servers must never mask, and the peer receiving a frame that breaks
+	// this rule must fail the connection.
+	if masked != c.isServer {
+		return h, &CloseError{Code: CloseProtocolError, Text: "websocket: incorrect frame masking"}
+	}
+
+	var maskKey [4]byte
+	if masked {
+		if _, err := io.ReadFull(c.br, maskKey[:]); err != nil {
+			return h, err
+		}
+	}
+
+	h.payload = make([]byte, length)
+	if _, err := io.ReadFull(c.br, h.payload); err != nil {
+		return h, err
+	}
+	if masked {
+		for i := range h.payload {
+			h.payload[i] ^= maskKey[i%4]
+		}
+	}
+	return h, nil
+}
+
+// closeErrorFromPayload decodes the status code and reason carried by an
+// OpClose frame's payload.
+func closeErrorFromPayload(payload []byte) *CloseError {
+	if len(payload) < 2 {
+		return &CloseError{Code: CloseNoStatusReceived}
+	}
+	return &CloseError{
+		Code: int(binary.BigEndian.Uint16(payload[:2])),
+		Text: string(payload[2:]),
+	}
+}
+
+// NextReader returns the opcode and a reader for the next data message
+// (OpText or OpBinary) sent by the peer. Control frames (ping, pong, close)
+// encountered along the way are dispatched to their handlers and do not
+// stop the wait for a data message, except for a close frame: once the peer
+// closes, NextReader (and all following calls) return the *CloseError
+// describing it.
+func (c *Conn) NextReader() (int, io.Reader, error) {
+	if c.readErr != nil {
+		return 0, nil, c.readErr
+	}
+
+	var message []byte
+	var opCode int
+	var compressed bool
+	var validator *utf8Validator
+	fin := false
+
+	for !fin {
+		h, err := c.readFrame()
+		if err != nil {
+			var ce *CloseError
+			if errors.As(err, &ce) {
+				c.readErr = ce
+				c.WriteControl(OpClose, FormatCloseMessage(ce.Code, ce.Text), time.Now().Add(writeWait))
+				return 0, nil, ce
+			}
+			c.readErr = err
+			return 0, nil, err
+		}
+
+		switch h.opCode {
+		case OpPing:
+			if c.pingHandler != nil {
+				if err := c.pingHandler(string(h.payload)); err != nil {
+					c.readErr = err
+					return 0, nil, err
+				}
+			}
+			continue
+		case OpPong:
+			if c.pongHandler != nil {
+				if err := c.pongHandler(string(h.payload)); err != nil {
+					c.readErr = err
+					return 0, nil, err
+				}
+			}
+			continue
+		case OpClose:
+			ce := closeErrorFromPayload(h.payload)
+			c.readErr = ce
+			c.WriteControl(OpClose, FormatCloseMessage(ce.Code, ""), time.Now().Add(writeWait))
+			return 0, nil, ce
+		}
+
+		if opCode == 0 {
+			opCode = h.opCode
+			compressed = h.rsv1
+			if opCode == OpText {
+				validator = new(utf8Validator)
+			}
+		}
+
+		// A compressed message is a single DEFLATE stream split across
+		// frames: it can only be inflated once every fragment has
+		// arrived, so fragments are accumulated here still compressed
+		// and UTF-8 is validated against the inflated result below
+		// instead of per fragment.
+		if !compressed && validator != nil && !validator.Validate(h.payload) {
+			ce := &CloseError{Code: CloseInvalidFramePayloadData}
+			c.readErr = ce
+			c.WriteControl(OpClose, FormatCloseMessage(ce.Code, ""), time.Now().Add(writeWait))
+			return 0, nil, ce
+		}
+
+		message = append(message, h.payload...)
+		fin = h.fin
+	}
+
+	if compressed {
+		payload, err := decompress(message, c.peerNoContextTakeover, &c.readDict)
+		if err != nil {
+			c.readErr = err
+			return 0, nil, err
+		}
+		message = payload
+	}
+
+	if validator != nil {
+		if (compressed && !validator.Validate(message)) || !validator.Complete() {
+			ce := &CloseError{Code: CloseInvalidFramePayloadData}
+			c.readErr = ce
+			return 0, nil, ce
+		}
+	}
+
+	return opCode, bytes.NewReader(message), nil
+}
+
+// messageWriter implements the io.WriteCloser returned by NextWriter: it
+// buffers the message in memory and sends it as a single frame on Close,
+// compressing it first when the connection negotiated permessage-deflate.
+type messageWriter struct {
+	c      *Conn
+	opCode int
+	buf    bytes.Buffer
+}
+
+// NextWriter returns a writer for a new message with the given opcode
+// (OpText or OpBinary). The message is not sent until the writer is closed.
+func (c *Conn) NextWriter(opCode int) (io.WriteCloser, error) {
+	return &messageWriter{c: c, opCode: opCode}, nil
+}
+
+func (w *messageWriter) Write(p []byte) (int, error) {
+	return w.buf.Write(p)
+}
+
+func (w *messageWriter) Close() error {
+	c := w.c
+	payload := w.buf.Bytes()
+	rsv1 := false
+
+	if c.enableCompression && len(payload) > 0 {
+		var compressed bytes.Buffer
+		cw := compressWriter(&compressed, c.compressionLevel, c.noContextTakeover, &c.writeDict)
+		if _, err := cw.Write(payload); err != nil {
+			return err
+		}
+		if err := cw.Close(); err != nil {
+			return err
+		}
+		payload = compressed.Bytes()
+		rsv1 = true
+	}
+
+	return c.writeRawFrame(true, rsv1, w.opCode, payload)
+}
+
+// writeRawFrame writes a single RFC 6455 frame, masking it if this Conn is
+// a client. It holds writeMu for the whole frame, so concurrent calls,
+// including those made through writeFrame, do not interleave their bytes.
+func (c *Conn) writeRawFrame(fin, rsv1 bool, opCode int, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	var b0 byte
+	if fin {
+		b0 |= 0x80
+	}
+	if rsv1 {
+		b0 |= 0x40
+	}
+	b0 |= byte(opCode & 0xf)
+
+	var header [14]byte
+	header[0] = b0
+	n := 1
+
+	var maskBit byte
+	if !c.isServer {
+		maskBit = 0x80
+	}
+
+	length := len(payload)
+	switch {
+	case length <= 125:
+		header[1] = maskBit | byte(length)
+		n = 2
+	case length <= 65535:
+		header[1] = maskBit | 126
+		binary.BigEndian.PutUint16(header[2:4], uint16(length))
+		n = 4
+	default:
+		header[1] = maskBit | 127
+		binary.BigEndian.PutUint64(header[2:10], uint64(length))
+		n = 10
+	}
+
+	var maskKey [4]byte
+	if !c.isServer {
+		if _, err := io.ReadFull(rand.Reader, maskKey[:]); err != nil {
+			return err
+		}
+		copy(header[n:n+4], maskKey[:])
+		n += 4
+	}
+
+	if _, err := c.conn.Write(header[:n]); err != nil {
+		return err
+	}
+	if len(payload) == 0 {
+		return nil
+	}
+
+	if !c.isServer {
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ maskKey[i%4]
+		}
+		_, err := c.conn.Write(masked)
+		return err
+	}
+
+	_, err := c.conn.Write(payload)
+	return err
+}
+
+// writeFrame writes a single, final, uncompressed frame. It is used by
+// WriteControl for control frames, which are never fragmented or
+// compressed.
+func (c *Conn) writeFrame(opCode int, payload []byte) error {
+	return c.writeRawFrame(true, false, opCode, payload)
+}
+
+// tokenListContainsValue reports whether any comma-separated token in any
+// value of header[name] equals value, ignoring case, as used to check the
+// Connection: Upgrade handshake header.
+func tokenListContainsValue(header http.Header, name, value string) bool {
+	for _, v := range header[http.CanonicalHeaderKey(name)] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// acceptKey computes the Sec-WebSocket-Accept value for challengeKey.
+func acceptKey(challengeKey string) string {
+	h := sha1.New()
+	h.Write([]byte(challengeKey))
+	h.Write(keyGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// Upgrade upgrades an HTTP server connection to a WebSocket connection.
+// It is a low-level entry point kept for backward compatibility; new code
+// should prefer Upgrader, which additionally validates the request Origin
+// and negotiates a subprotocol.
+func Upgrade(w http.ResponseWriter, requestHeader http.Header, responseHeader http.Header, readBufSize, writeBufSize int) (*Conn, error) {
+	if !strings.EqualFold(requestHeader.Get("Upgrade"), "websocket") {
+		return nil, HandshakeError{"websocket: not a websocket handshake: missing or invalid Upgrade header"}
+	}
+	if !tokenListContainsValue(requestHeader, "Connection", "upgrade") {
+		return nil, HandshakeError{"websocket: not a websocket handshake: missing or invalid Connection header"}
+	}
+	challengeKey := requestHeader.Get("Sec-WebSocket-Key")
+	if challengeKey == "" {
+		return nil, HandshakeError{"websocket: not a websocket handshake: missing Sec-WebSocket-Key"}
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		return nil, errors.New("websocket: response does not implement http.Hijacker")
+	}
+	netConn, brw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	c := newConn(netConn, true)
+	if brw != nil && brw.Reader != nil {
+		c.br = brw.Reader
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("HTTP/1.1 101 Switching Protocols\r\n")
+	buf.WriteString("Upgrade: websocket\r\n")
+	buf.WriteString("Connection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Accept: " + acceptKey(challengeKey) + "\r\n")
+	for k, vs := range responseHeader {
+		for _, v := range vs {
+			fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+// generateChallengeKey returns a new, randomly generated Sec-WebSocket-Key.
+func generateChallengeKey() (string, error) {
+	var key [16]byte
+	if _, err := io.ReadFull(rand.Reader, key[:]); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key[:]), nil
+}
+
+// NewClient performs the WebSocket client handshake on an already-dialed
+// net.Conn and returns the resulting *Conn along with the server's HTTP
+// response (so callers can inspect headers such as Set-Cookie). It always
+// advertises permessage-deflate support; if the server accepts it, frames
+// are transparently compressed and decompressed from then on.
+func NewClient(netConn net.Conn, u *url.URL, requestHeader http.Header, readBufSize, writeBufSize int) (*Conn, *http.Response, error) {
+	challengeKey, err := generateChallengeKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "GET %s HTTP/1.1\r\n", u.RequestURI())
+	buf.WriteString("Host: " + u.Host + "\r\n")
+	buf.WriteString("Upgrade: websocket\r\n")
+	buf.WriteString("Connection: Upgrade\r\n")
+	buf.WriteString("Sec-WebSocket-Key: " + challengeKey + "\r\n")
+	buf.WriteString("Sec-WebSocket-Version: 13\r\n")
+	buf.WriteString("Sec-WebSocket-Extensions: " + permessageDeflate + "\r\n")
+	for k, vs := range requestHeader {
+		for _, v := range vs {
+			fmt.Fprintf(&buf, "%s: %s\r\n", k, v)
+		}
+	}
+	buf.WriteString("\r\n")
+
+	if _, err := netConn.Write(buf.Bytes()); err != nil {
+		return nil, nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "GET"})
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		return nil, resp, HandshakeError{"websocket: bad handshake: " + resp.Status}
+	}
+	if !strings.EqualFold(resp.Header.Get("Sec-WebSocket-Accept"), acceptKey(challengeKey)) {
+		return nil, resp, HandshakeError{"websocket: bad handshake: invalid Sec-WebSocket-Accept"}
+	}
+
+	c := newConn(netConn, false)
+	c.br = br
+	c.subprotocol = resp.Header.Get("Sec-WebSocket-Protocol")
+
+	if extension := resp.Header.Get("Sec-WebSocket-Extensions"); strings.Contains(extension, permessageDeflate) {
+		c.enableCompression = true
+		c.noContextTakeover = peerRequestedNoContextTakeover(extension)
+		c.peerNoContextTakeover = c.noContextTakeover
+	}
+
+	return c, resp, nil
+}
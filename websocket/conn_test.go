@@ -0,0 +1,168 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net"
+	"testing"
+)
+
+func pipeConns() (server, client *Conn) {
+	serverNetConn, clientNetConn := net.Pipe()
+	return newConn(serverNetConn, true), newConn(clientNetConn, false)
+}
+
+func TestNextReaderFragmentedCompressedMessage(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+	server.enableCompression = true
+	client.enableCompression = true
+
+	payload := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 50)
+
+	done := make(chan error, 1)
+	go func() {
+		var compressed bytes.Buffer
+		cw := compressWriter(&compressed, client.compressionLevel, client.noContextTakeover, &client.writeDict)
+		if _, err := cw.Write(payload); err != nil {
+			done <- err
+			return
+		}
+		if err := cw.Close(); err != nil {
+			done <- err
+			return
+		}
+
+		// Split the compressed stream across three frames to exercise
+		// fragment reassembly: only the first carries rsv1.
+		b := compressed.Bytes()
+		third := len(b) / 3
+		if err := client.writeRawFrame(false, true, OpBinary, b[:third]); err != nil {
+			done <- err
+			return
+		}
+		if err := client.writeRawFrame(false, false, OpContinuation, b[third:2*third]); err != nil {
+			done <- err
+			return
+		}
+		done <- client.writeRawFrame(true, false, OpContinuation, b[2*third:])
+	}()
+
+	opCode, r, err := server.NextReader()
+	if err != nil {
+		t.Fatalf("NextReader: %v", err)
+	}
+	if opCode != OpBinary {
+		t.Fatalf("opCode = %d, want %d", opCode, OpBinary)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if !bytes.Equal(got, payload) {
+		t.Fatalf("got %d bytes, want %d bytes", len(got), len(payload))
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("writer goroutine: %v", err)
+	}
+}
+
+// drainCloseFrame reads (and discards) whatever NextReader's caller writes
+// back in response to a protocol violation, so the test doesn't block on
+// that write's deadline when nothing would otherwise read it.
+func drainCloseFrame(c *Conn) {
+	go c.NextReader()
+}
+
+func TestReadFrameRejectsUnmaskedClientFrame(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+	drainCloseFrame(client)
+
+	// A real client always masks; simulate a non-conformant one by writing
+	// onto the same pipe through a Conn configured not to mask.
+	unmasked := newConn(client.conn, true)
+	go unmasked.writeFrame(OpBinary, []byte("hi"))
+
+	if _, _, err := server.NextReader(); !IsCloseError(err, CloseProtocolError) {
+		t.Fatalf("NextReader err = %v, want *CloseError with CloseProtocolError", err)
+	}
+}
+
+func TestReadFrameRejectsReservedOpcode(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+	drainCloseFrame(client)
+
+	go client.writeRawFrame(true, false, 3, nil)
+
+	if _, _, err := server.NextReader(); !IsCloseError(err, CloseProtocolError) {
+		t.Fatalf("NextReader err = %v, want *CloseError with CloseProtocolError", err)
+	}
+}
+
+func TestReadFrameRejectsOversizedControlFrame(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+	drainCloseFrame(client)
+
+	go client.writeRawFrame(true, false, OpPing, bytes.Repeat([]byte("x"), 126))
+
+	if _, _, err := server.NextReader(); !IsCloseError(err, CloseProtocolError) {
+		t.Fatalf("NextReader err = %v, want *CloseError with CloseProtocolError", err)
+	}
+}
+
+func TestReadFrameRejectsFragmentedControlFrame(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+	drainCloseFrame(client)
+
+	go client.writeRawFrame(false, false, OpPing, []byte("hi"))
+
+	if _, _, err := server.NextReader(); !IsCloseError(err, CloseProtocolError) {
+		t.Fatalf("NextReader err = %v, want *CloseError with CloseProtocolError", err)
+	}
+}
+
+func TestReadFrameAcceptsUnmaskedServerFrame(t *testing.T) {
+	server, client := pipeConns()
+	defer server.Close()
+	defer client.Close()
+
+	go server.writeFrame(OpBinary, []byte("hi"))
+
+	opCode, r, err := client.NextReader()
+	if err != nil {
+		t.Fatalf("NextReader: %v", err)
+	}
+	if opCode != OpBinary {
+		t.Fatalf("opCode = %d, want %d", opCode, OpBinary)
+	}
+	got, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("ReadAll: %v", err)
+	}
+	if string(got) != "hi" {
+		t.Fatalf("got %q, want %q", got, "hi")
+	}
+}
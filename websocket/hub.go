@@ -0,0 +1,99 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"sync"
+	"time"
+)
+
+// outboundQueueSize is the number of pending broadcast messages a single
+// client may buffer before the Hub considers it too slow and drops it.
+const outboundQueueSize = 16
+
+// Hub fans a message out to a set of registered connections. Each
+// connection gets its own bounded outbound queue and dedicated writer
+// goroutine, so a slow or stuck client cannot block delivery to the rest of
+// the hub; it is instead dropped with CloseTryAgainLater. Hub is safe for
+// concurrent use by multiple goroutines.
+type Hub struct {
+	mu      sync.Mutex
+	clients map[*BinaryConn]chan []byte
+}
+
+// NewHub creates an empty Hub.
+func NewHub() *Hub {
+	return &Hub{
+		clients: make(map[*BinaryConn]chan []byte),
+	}
+}
+
+// Register adds conn to the hub and starts its writer goroutine. conn
+// starts receiving any message passed to Broadcast after this call returns.
+func (h *Hub) Register(conn *BinaryConn) {
+	outbound := make(chan []byte, outboundQueueSize)
+
+	h.mu.Lock()
+	h.clients[conn] = outbound
+	h.mu.Unlock()
+
+	go h.writeLoop(conn, outbound)
+}
+
+// Unregister removes conn from the hub and closes its outbound queue,
+// stopping its writer goroutine. It is safe to call Unregister more than
+// once, or for a conn that was never registered.
+func (h *Hub) Unregister(conn *BinaryConn) {
+	h.mu.Lock()
+	outbound, ok := h.clients[conn]
+	delete(h.clients, conn)
+	h.mu.Unlock()
+
+	if ok {
+		close(outbound)
+	}
+}
+
+// Broadcast enqueues data for delivery to every connection currently
+// registered with the hub. A client whose outbound queue is full is
+// dropped: it is unregistered and sent a CloseTryAgainLater close message,
+// rather than letting it stall delivery to everyone else.
+func (h *Hub) Broadcast(data []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for conn, outbound := range h.clients {
+		select {
+		case outbound <- data:
+		default:
+			delete(h.clients, conn)
+			close(outbound)
+			go func(conn *BinaryConn) {
+				conn.ws.WriteCloseMessage(CloseTryAgainLater, "outbound queue full", time.Now().Add(writeWait))
+				conn.Close()
+			}(conn)
+		}
+	}
+}
+
+// writeLoop drains outbound and writes each message to conn until outbound
+// is closed (by Unregister or by Broadcast dropping a slow client).
+func (h *Hub) writeLoop(conn *BinaryConn, outbound chan []byte) {
+	for data := range outbound {
+		if _, err := conn.Write(data); err != nil {
+			h.Unregister(conn)
+			return
+		}
+	}
+}
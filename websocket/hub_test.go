@@ -0,0 +1,76 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket_test
+
+import (
+	"github.com/zhangpeihao/go-websocket/websocket"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type wsHubHandler struct {
+	*testing.T
+	hub *websocket.Hub
+}
+
+func (h wsHubHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.NewBianryConn(w, r, nil, 1024, 1024)
+	if err != nil {
+		h.Logf("upgrade error: %v", err)
+		return
+	}
+	h.hub.Register(conn)
+	defer h.hub.Unregister(conn)
+
+	// Block until the client disconnects; delivery happens on the hub's
+	// writer goroutine for this connection.
+	ioutil.ReadAll(conn)
+}
+
+func TestHubBroadcast(t *testing.T) {
+	hub := websocket.NewHub()
+	s := httptest.NewServer(wsHubHandler{t, hub})
+	defer s.Close()
+
+	const numClients = 3
+	conns := make([]*websocket.BinaryConn, numClients)
+	for i := range conns {
+		conn, _, err := websocket.Connect(s.URL, 1024, 1024)
+		if err != nil {
+			t.Fatalf("Connect %d err: %v", i, err)
+		}
+		defer conn.Close()
+		conns[i] = conn
+	}
+
+	// Give the server time to register every connection with the hub.
+	time.Sleep(100 * time.Millisecond)
+
+	hub.Broadcast([]byte("hello"))
+
+	for i, conn := range conns {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		b, err := ioutil.ReadAll(conn)
+		if err != nil {
+			t.Fatalf("client %d ReadAll: %v", i, err)
+		}
+		if string(b) != "hello" {
+			t.Fatalf("client %d got %q, want %q", i, b, "hello")
+		}
+	}
+}
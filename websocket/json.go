@@ -0,0 +1,43 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import "encoding/json"
+
+// WriteJSON writes the JSON encoding of v as a text message. It encodes
+// through json.NewEncoder on top of NextWriter, which holds the whole
+// message in memory until Close sends it as a single frame.
+func (c *Conn) WriteJSON(v interface{}) error {
+	w, err := c.NextWriter(OpText)
+	if err != nil {
+		return err
+	}
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		w.Close()
+		return err
+	}
+	return w.Close()
+}
+
+// ReadJSON reads the next message and decodes it as JSON into v. It
+// decodes through json.NewDecoder on top of NextReader, which returns the
+// message only after it has been read into memory in full.
+func (c *Conn) ReadJSON(v interface{}) error {
+	_, r, err := c.NextReader()
+	if err != nil {
+		return err
+	}
+	return json.NewDecoder(r).Decode(v)
+}
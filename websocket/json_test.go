@@ -0,0 +1,78 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket_test
+
+import (
+	"github.com/zhangpeihao/go-websocket/websocket"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type jsonEchoHandler struct {
+	*testing.T
+}
+
+func (t jsonEchoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Upgrade(w, r.Header, nil, 1024, 1024)
+	if err != nil {
+		t.Logf("upgrade error: %v", err)
+		return
+	}
+	defer ws.Close()
+
+	var msg map[string]string
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Logf("ReadJSON error: %v", err)
+		return
+	}
+	if err := ws.WriteJSON(msg); err != nil {
+		t.Logf("WriteJSON error: %v", err)
+	}
+}
+
+func TestWriteReadJSON(t *testing.T) {
+	s := httptest.NewServer(jsonEchoHandler{t})
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal("url.Parse err:", err)
+	}
+	c, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal("Dial err:", err)
+	}
+	ws, _, err := websocket.NewClient(c, u, http.Header{"Origin": {s.URL}}, 1024, 1024)
+	if err != nil {
+		t.Fatal("NewClient err:", err)
+	}
+	defer ws.Close()
+
+	want := map[string]string{"hello": "world"}
+	if err := ws.WriteJSON(want); err != nil {
+		t.Fatal("WriteJSON err:", err)
+	}
+
+	var got map[string]string
+	if err := ws.ReadJSON(&got); err != nil {
+		t.Fatal("ReadJSON err:", err)
+	}
+	if got["hello"] != want["hello"] {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+}
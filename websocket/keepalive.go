@@ -0,0 +1,98 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// SetPingHandler sets the handler invoked when a ping control frame is
+// received from the peer. The appData argument is the (possibly empty)
+// application data carried by the frame. The default handler replies with a
+// pong carrying the same application data.
+//
+// The handler is called from the goroutine that calls NextReader, Read or
+// ReadJSON, so it must not block.
+func (c *Conn) SetPingHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(appData string) error {
+			return c.WriteControl(OpPong, []byte(appData), time.Now().Add(writeWait))
+		}
+	}
+	c.pingHandler = h
+}
+
+// SetPongHandler sets the handler invoked when a pong control frame is
+// received from the peer. There is no default handler; EnableKeepalive
+// installs one that extends the read deadline.
+//
+// The handler is called from the goroutine that calls NextReader, Read or
+// ReadJSON, so it must not block.
+func (c *Conn) SetPongHandler(h func(appData string) error) {
+	if h == nil {
+		h = func(string) error { return nil }
+	}
+	c.pongHandler = h
+}
+
+// writeWait bounds how long a control frame write (ping, pong, close) may
+// block before giving up.
+const writeWait = 10 * time.Second
+
+// WriteControl writes a control message (OpClose, OpPing or OpPong) to the
+// connection with the given deadline. Unlike NextWriter, WriteControl may be
+// called concurrently with NextWriter/Write/WriteJSON from another
+// goroutine, since every frame write is serialized through writeMu.
+func (c *Conn) WriteControl(opCode int, data []byte, deadline time.Time) error {
+	c.writeControlMu.Lock()
+	defer c.writeControlMu.Unlock()
+	if err := c.conn.SetWriteDeadline(deadline); err != nil {
+		return err
+	}
+	return c.writeFrame(opCode, data)
+}
+
+// EnableKeepalive starts a goroutine that writes a ping every interval and
+// closes the connection if a pong is not observed within timeout of the
+// previous one. Each received pong extends the read deadline by timeout,
+// giving the peer a full timeout window to answer the next ping. Callers
+// should not also set a read deadline manually while keepalive is enabled.
+//
+// EnableKeepalive installs its own pong handler via SetPongHandler,
+// overwriting any handler set previously.
+func (conn *BinaryConn) EnableKeepalive(interval, timeout time.Duration) {
+	lastPong := time.Now().UnixNano()
+	conn.ws.SetPongHandler(func(string) error {
+		atomic.StoreInt64(&lastPong, time.Now().UnixNano())
+		return conn.ws.SetReadDeadline(time.Now().Add(timeout))
+	})
+	conn.ws.SetReadDeadline(time.Now().Add(timeout))
+
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for range ticker.C {
+			if time.Since(time.Unix(0, atomic.LoadInt64(&lastPong))) > timeout {
+				conn.Close()
+				return
+			}
+			if err := conn.ws.WriteControl(OpPing, nil, time.Now().Add(writeWait)); err != nil {
+				conn.Close()
+				return
+			}
+		}
+	}()
+}
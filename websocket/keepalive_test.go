@@ -0,0 +1,64 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket_test
+
+import (
+	"github.com/zhangpeihao/go-websocket/websocket"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type wsKeepaliveHandler struct {
+	*testing.T
+	done chan struct{}
+}
+
+func (t wsKeepaliveHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.NewBianryConn(w, r, nil, 1024, 1024)
+	if err != nil {
+		t.Logf("upgrade error: %v", err)
+		return
+	}
+	defer conn.Close()
+	conn.EnableKeepalive(50*time.Millisecond, 200*time.Millisecond)
+	buf := make([]byte, 1024)
+	for {
+		if _, err := conn.Read(buf); err != nil {
+			close(t.done)
+			return
+		}
+	}
+}
+
+func TestEnableKeepaliveDetectsDeadPeer(t *testing.T) {
+	done := make(chan struct{})
+	s := httptest.NewServer(wsKeepaliveHandler{t, done})
+	defer s.Close()
+
+	conn, _, err := websocket.Connect(s.URL, 1024, 1024)
+	if err != nil {
+		t.Fatal("Connect err:", err)
+	}
+
+	select {
+	case <-done:
+		// Server gave up on the silent peer, as expected.
+	case <-time.After(2 * time.Second):
+		t.Fatal("server did not detect the dead peer in time")
+	}
+	conn.Close()
+}
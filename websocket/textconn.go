@@ -0,0 +1,82 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"bytes"
+	"io"
+)
+
+// TextConn is the text-message companion to BinaryConn: it implements
+// io.ReadWriter over a websocket connection, but frames Read and Write
+// around OpText instead of OpBinary. As with BinaryConn, Read discards
+// control frames and surfaces a *CloseError when the peer closes.
+type TextConn struct {
+	ws *Conn
+}
+
+// NewTextConn wraps an already-established *Conn as a TextConn.
+func NewTextConn(ws *Conn) *TextConn {
+	return &TextConn{ws: ws}
+}
+
+// Read reads the next text message from the connection.
+func (conn *TextConn) Read(b []byte) (n int, err error) {
+	var opCode int
+	var r io.Reader
+	var length int64
+FOR_LOOP:
+	for {
+		if opCode, r, err = conn.ws.NextReader(); err != nil {
+			return
+		}
+		switch opCode {
+		case OpPong:
+			continue FOR_LOOP
+		case OpText:
+			dst := bytes.NewBuffer(b)
+			dst.Reset()
+
+			length, err = io.CopyN(dst, r, int64(len(b)))
+			if err != nil {
+				if err == io.EOF {
+					n = dst.Len()
+				}
+				return
+			}
+			n = int(length)
+			return
+		}
+	}
+}
+
+// Write writes b to the connection as a single text message. b must be
+// valid UTF-8; the package does not validate it.
+func (conn *TextConn) Write(b []byte) (n int, err error) {
+	var w io.WriteCloser
+	if w, err = conn.ws.NextWriter(OpText); err != nil {
+		return
+	}
+	if n, err = w.Write(b); err != nil {
+		return
+	}
+	err = w.Close()
+	return
+}
+
+// Close closes the connection.
+func (conn *TextConn) Close() error {
+	return conn.ws.Close()
+}
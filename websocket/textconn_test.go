@@ -0,0 +1,79 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket_test
+
+import (
+	"github.com/zhangpeihao/go-websocket/websocket"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+type wsTextHandler struct {
+	*testing.T
+}
+
+func (t wsTextHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ws, err := websocket.Upgrade(w, r.Header, nil, 1024, 1024)
+	if err != nil {
+		t.Logf("upgrade error: %v", err)
+		return
+	}
+	conn := websocket.NewTextConn(ws)
+	defer conn.Close()
+
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Logf("ReadAll error: %v", err)
+		return
+	}
+	if _, err := conn.Write(b); err != nil {
+		t.Logf("Write error: %v", err)
+	}
+}
+
+func TestTextConn(t *testing.T) {
+	s := httptest.NewServer(wsTextHandler{t})
+	defer s.Close()
+
+	u, err := url.Parse(s.URL)
+	if err != nil {
+		t.Fatal("url.Parse err:", err)
+	}
+	c, err := net.Dial("tcp", u.Host)
+	if err != nil {
+		t.Fatal("Dial err:", err)
+	}
+	ws, _, err := websocket.NewClient(c, u, http.Header{"Origin": {s.URL}}, 1024, 1024)
+	if err != nil {
+		t.Fatal("NewClient err:", err)
+	}
+	conn := websocket.NewTextConn(ws)
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("HELLO")); err != nil {
+		t.Fatal("Write err:", err)
+	}
+	b, err := ioutil.ReadAll(conn)
+	if err != nil {
+		t.Fatal("ReadAll err:", err)
+	}
+	if string(b) != "HELLO" {
+		t.Fatalf("got %q, want %q", b, "HELLO")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultBufferSize is used for ReadBufferSize/WriteBufferSize when an
+// Upgrader leaves them at zero.
+const defaultBufferSize = 4096
+
+// Upgrader configures the parameters used to upgrade an HTTP connection to
+// a WebSocket connection. Upgrade is the configurable replacement for the
+// package-level Upgrade function, which remains available for backward
+// compatibility and which Upgrader.Upgrade calls to perform the handshake.
+type Upgrader struct {
+	// ReadBufferSize and WriteBufferSize are passed to the package-level
+	// Upgrade, defaulting to defaultBufferSize when zero. Upgrade does not
+	// use them: the resulting Conn reads through the hijacked connection's
+	// bufio.Reader and writes frames directly to the connection.
+	ReadBufferSize, WriteBufferSize int
+
+	// HandshakeTimeout is not read by Upgrade; the handshake response is
+	// written without a deadline whatever its value.
+	HandshakeTimeout time.Duration
+
+	// Subprotocols lists the server's supported subprotocols in order of
+	// preference. Upgrade picks the first entry here that the client
+	// also offered in Sec-WebSocket-Protocol and echoes it back.
+	Subprotocols []string
+
+	// CheckOrigin returns true if the request Origin header is
+	// acceptable. If nil, Upgrade uses a safe default that only allows
+	// requests with no Origin header or whose Origin matches the request
+	// Host.
+	CheckOrigin func(r *http.Request) bool
+
+	// Error, if set, is called instead of the default plain-text error
+	// response whenever the handshake fails, letting the caller
+	// customize the response body.
+	Error func(w http.ResponseWriter, r *http.Request, status int, reason error)
+
+	// EnableCompression negotiates the permessage-deflate extension (RFC
+	// 7692) when the client offers it in Sec-WebSocket-Extensions.
+	EnableCompression bool
+
+	// CompressionLevel is the flate compression level used once
+	// compression is negotiated. Zero means the package default (see
+	// SetDefaultCompressionLevel).
+	CompressionLevel int
+
+	// NoContextTakeover disables the sliding compression window between
+	// messages, trading compression ratio for lower per-connection
+	// memory use.
+	NoContextTakeover bool
+}
+
+// Upgrade upgrades the HTTP connection to a WebSocket connection, validating
+// the request's Origin header and negotiating a subprotocol before handing
+// off to the low-level handshake. The negotiated subprotocol, if any, is
+// available afterward via Conn.Subprotocol.
+func (u *Upgrader) Upgrade(w http.ResponseWriter, r *http.Request, responseHeader http.Header) (*Conn, error) {
+	checkOrigin := u.CheckOrigin
+	if checkOrigin == nil {
+		checkOrigin = defaultCheckSameOrigin
+	}
+	if !checkOrigin(r) {
+		return u.onError(w, r, http.StatusForbidden, errors.New("websocket: request origin not allowed by Upgrader.CheckOrigin"))
+	}
+
+	subprotocol := selectSubprotocol(r.Header.Get("Sec-WebSocket-Protocol"), u.Subprotocols)
+	if subprotocol != "" {
+		if responseHeader == nil {
+			responseHeader = http.Header{}
+		}
+		responseHeader.Set("Sec-WebSocket-Protocol", subprotocol)
+	}
+
+	var extensionResponse string
+	var compress bool
+	if u.EnableCompression {
+		extensionResponse, compress = negotiateCompressionExtensions(r.Header["Sec-WebSocket-Extensions"], u.NoContextTakeover)
+		if compress {
+			if responseHeader == nil {
+				responseHeader = http.Header{}
+			}
+			responseHeader.Set("Sec-WebSocket-Extensions", extensionResponse)
+		}
+	}
+
+	readBufSize, writeBufSize := u.ReadBufferSize, u.WriteBufferSize
+	if readBufSize == 0 {
+		readBufSize = defaultBufferSize
+	}
+	if writeBufSize == 0 {
+		writeBufSize = defaultBufferSize
+	}
+
+	conn, err := Upgrade(w, r.Header, responseHeader, readBufSize, writeBufSize)
+	if err != nil {
+		return u.onError(w, r, http.StatusBadRequest, err)
+	}
+	conn.subprotocol = subprotocol
+	if compress {
+		conn.enableCompression = true
+		conn.noContextTakeover = u.NoContextTakeover
+		conn.peerNoContextTakeover = peerRequestedNoContextTakeover(r.Header.Get("Sec-WebSocket-Extensions"))
+		if u.CompressionLevel != 0 {
+			conn.compressionLevel = u.CompressionLevel
+		}
+	}
+	return conn, nil
+}
+
+func (u *Upgrader) onError(w http.ResponseWriter, r *http.Request, status int, reason error) (*Conn, error) {
+	if u.Error != nil {
+		u.Error(w, r, status, reason)
+	} else {
+		http.Error(w, http.StatusText(status), status)
+	}
+	return nil, reason
+}
+
+// defaultCheckSameOrigin is the CheckOrigin used when an Upgrader does not
+// set one: it allows the request only when the Origin header is empty (not
+// a browser request) or matches the request Host.
+func defaultCheckSameOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	return origin == "http://"+r.Host || origin == "https://"+r.Host
+}
+
+// selectSubprotocol returns the first protocol in supported that the client
+// also listed in the Sec-WebSocket-Protocol header value requested, or the
+// empty string if none match.
+func selectSubprotocol(requested string, supported []string) string {
+	if requested == "" || len(supported) == 0 {
+		return ""
+	}
+	clientProtocols := make(map[string]bool)
+	for _, p := range strings.Split(requested, ",") {
+		clientProtocols[strings.TrimSpace(p)] = true
+	}
+	for _, p := range supported {
+		if clientProtocols[p] {
+			return p
+		}
+	}
+	return ""
+}
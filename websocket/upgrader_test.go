@@ -0,0 +1,36 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import "testing"
+
+func TestSelectSubprotocol(t *testing.T) {
+	cases := []struct {
+		requested string
+		supported []string
+		want      string
+	}{
+		{"chat, superchat", []string{"superchat"}, "superchat"},
+		{"chat, superchat", []string{"superchat", "chat"}, "superchat"},
+		{"chat", []string{"superchat"}, ""},
+		{"", []string{"superchat"}, ""},
+		{"chat", nil, ""},
+	}
+	for _, c := range cases {
+		if got := selectSubprotocol(c.requested, c.supported); got != c.want {
+			t.Errorf("selectSubprotocol(%q, %v) = %q, want %q", c.requested, c.supported, got, c.want)
+		}
+	}
+}
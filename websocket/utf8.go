@@ -0,0 +1,64 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import "unicode/utf8"
+
+// utf8Validator checks a stream of text-frame fragments for valid UTF-8
+// without buffering the whole message, so a bad sequence split across two
+// fragments (or even two reads of the same fragment) is still caught. The
+// reader for OpText frames feeds every chunk it reads through Validate and,
+// on a false return, fails the message with CloseInvalidFramePayloadData
+// per RFC 6455 Section 8.1, matching the Autobahn testsuite's UTF-8 cases.
+type utf8Validator struct {
+	// pending holds the tail of the last chunk that could not yet be
+	// proven valid because it ended mid rune.
+	pending [utf8.UTFMax - 1]byte
+	npend   int
+}
+
+// Validate checks p, which is a fragment of a larger text message
+// (pending bytes from the previous call included), and reports whether
+// everything read so far is valid UTF-8. Call Complete after the last
+// fragment of the message to confirm it didn't end mid rune.
+func (v *utf8Validator) Validate(p []byte) bool {
+	if v.npend > 0 {
+		p = append(append([]byte(nil), v.pending[:v.npend]...), p...)
+		v.npend = 0
+	}
+
+	for len(p) > 0 {
+		r, size := utf8.DecodeRune(p)
+		if r != utf8.RuneError || size != 1 {
+			p = p[size:]
+			continue
+		}
+		// A single invalid byte is only tolerable if it's actually an
+		// incomplete rune straddling this chunk and the next one.
+		if utf8.RuneStart(p[0]) && !utf8.FullRune(p) {
+			v.npend = copy(v.pending[:], p)
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// Complete reports whether the validated stream ended on a full rune
+// boundary; it must be called once Validate has seen every fragment of the
+// message.
+func (v *utf8Validator) Complete() bool {
+	return v.npend == 0
+}
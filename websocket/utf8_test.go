@@ -0,0 +1,58 @@
+// Copyright 2012, 2013 Gary Burd & Zhang Peihao
+//
+// Licensed under the Apache License, Version 2.0 (the "License"): you may
+// not use this file except in compliance with the License. You may obtain
+// a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS, WITHOUT
+// WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied. See the
+// License for the specific language governing permissions and limitations
+// under the License.
+
+package websocket
+
+import "testing"
+
+func TestUTF8ValidatorValid(t *testing.T) {
+	v := new(utf8Validator)
+	if !v.Validate([]byte("héllo, 世界")) {
+		t.Fatal("Validate() = false for valid UTF-8")
+	}
+	if !v.Complete() {
+		t.Fatal("Complete() = false after a full message")
+	}
+}
+
+func TestUTF8ValidatorSplitRune(t *testing.T) {
+	msg := "héllo"
+	v := new(utf8Validator)
+	for i := range []byte(msg) {
+		if !v.Validate([]byte(msg)[i : i+1]) {
+			t.Fatalf("Validate() = false at byte %d of valid split message", i)
+		}
+	}
+	if !v.Complete() {
+		t.Fatal("Complete() = false after a valid split message")
+	}
+}
+
+func TestUTF8ValidatorInvalid(t *testing.T) {
+	v := new(utf8Validator)
+	if v.Validate([]byte{0xff, 0xfe}) {
+		t.Fatal("Validate() = true for invalid UTF-8")
+	}
+}
+
+func TestUTF8ValidatorTruncated(t *testing.T) {
+	v := new(utf8Validator)
+	// The first byte of a two-byte sequence with nothing to follow.
+	if !v.Validate([]byte{0xc2}) {
+		t.Fatal("Validate() = false for a rune still pending more bytes")
+	}
+	if v.Complete() {
+		t.Fatal("Complete() = true for a message that ended mid rune")
+	}
+}